@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/webp"
+)
+
+// errRawConverterNotConfigured 表示 RAW/HEIC 文件因为没有配置 RawConverterCmd
+// 而被跳过——这是预期中的“跳过”，不是处理失败，调用方不应计入 Report.Failed。
+var errRawConverterNotConfigured = errors.New("未配置 RawConverterCmd，跳过 RAW/HEIC 文件")
+
+// sourceFormat 是解码前识别出的输入文件格式。
+type sourceFormat int
+
+const (
+	formatJPEG sourceFormat = iota
+	formatPNG
+	formatWebP
+	formatRawOrHEIC // 需要借助外部转换器（libraw/heif-convert）
+)
+
+// rawHEICExts 是已知需要外部转换器处理的 RAW/HEIC 扩展名。
+var rawHEICExts = map[string]bool{
+	".heic": true,
+	".heif": true,
+	".cr2":  true,
+	".nef":  true,
+	".arw":  true,
+	".dng":  true,
+	".raw":  true,
+}
+
+// outputRelPath 根据实际编码出的格式重写输出文件的扩展名：PNG 源保持 .png，
+// 其余（JPEG、WebP 解码后、RAW/HEIC 转换后）一律落地为 .jpg，
+// 避免 WebP/RAW 源文件的原扩展名被直接套用到实际是 JPEG 字节的输出文件上。
+func outputRelPath(relPath string, format sourceFormat) string {
+	if format != formatJPEG {
+		return relPath
+	}
+	ext := filepath.Ext(relPath)
+	lower := strings.ToLower(ext)
+	if lower == ".jpg" || lower == ".jpeg" {
+		return relPath
+	}
+	return strings.TrimSuffix(relPath, ext) + ".jpg"
+}
+
+// supportedExt 判断某个扩展名（小写，含点）是否是本工具认识的输入格式。
+func supportedExt(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return true
+	}
+	return rawHEICExts[ext]
+}
+
+// sniffFormat 优先按扩展名判断格式，扩展名不认识或不确定时退回到文件头的魔数。
+func sniffFormat(name string, head []byte) sourceFormat {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".png":
+		return formatPNG
+	case ".webp":
+		return formatWebP
+	case ".jpg", ".jpeg":
+		return formatJPEG
+	}
+	if rawHEICExts[ext] {
+		return formatRawOrHEIC
+	}
+
+	switch {
+	case len(head) >= 8 && bytes.Equal(head[:8], pngSignature):
+		return formatPNG
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return formatWebP
+	default:
+		return formatJPEG
+	}
+}
+
+// decodeResult 汇总一次解码得到的图像与需要透传的元数据。
+type decodeResult struct {
+	img          image.Image
+	format       sourceFormat
+	exif         *Exif
+	metaSegments []metaSegment // JPEG 专用：APP1/APP2/APP13 等
+	pngChunks    []pngChunk    // PNG 专用：tEXt/iTXt/eXIf 等
+}
+
+// normalizeOrientationMetadata 在像素已经按 Exif 方向标签物理旋转之后调用，
+// 把输出里残留的方向标签重写为 1，避免浏览器、相册等会读 Exif 的下游消费者
+// 在已经摆正的图片上再转一次。JPEG 的 Exif 段直接引用 decoded.metaSegments
+// 底层的字节数组，原地改写即可生效；PNG 的 eXIf 块在解析时被拷贝进了带
+// "Exif\0\0" 前缀的新缓冲区，所以改写后还要把裁掉前缀的结果写回对应的块。
+func normalizeOrientationMetadata(d *decodeResult) {
+	if d.exif == nil {
+		return
+	}
+	d.exif.SetOrientation(1)
+	if d.format != formatPNG {
+		return
+	}
+	tiff := d.exif.Raw[6:]
+	for i := range d.pngChunks {
+		if string(d.pngChunks[i].typ[:]) == "eXIf" {
+			d.pngChunks[i].data = tiff
+		}
+	}
+}
+
+// decodeSource 按格式解码输入文件：JPEG 走既有的标记段解析；PNG 额外保留
+// tEXt/iTXt/eXIf 等辅助块；WebP 直接解码；RAW/HEIC 在配置了外部转换器时
+// 先转换成中间 JPEG 再沿用 JPEG 路径（含从内嵌缩略图读取方向的场景）。
+func decodeSource(path string, cfg Config) (*decodeResult, error) {
+	origBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	head := origBytes
+	if len(head) > 32 {
+		head = head[:32]
+	}
+	format := sniffFormat(path, head)
+
+	switch format {
+	case formatPNG:
+		img, err := png.Decode(bytes.NewReader(origBytes))
+		if err != nil {
+			return nil, fmt.Errorf("PNG 解码失败: %w", err)
+		}
+		chunks := extractPNGChunks(origBytes)
+		return &decodeResult{img: img, format: formatPNG, exif: exifFromPNGChunks(chunks), pngChunks: chunks}, nil
+
+	case formatWebP:
+		img, err := webp.Decode(bytes.NewReader(origBytes))
+		if err != nil {
+			return nil, fmt.Errorf("WebP 解码失败: %w", err)
+		}
+		return &decodeResult{img: img, format: formatJPEG}, nil
+
+	case formatRawOrHEIC:
+		jpegBytes, err := convertRawToJPEG(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		metaSegments := extractMetaSegments(jpegBytes)
+		exif := exifFromSegments(metaSegments)
+		img, _, err := image.Decode(bytes.NewReader(jpegBytes))
+		if err != nil {
+			return nil, fmt.Errorf("转换后的 JPEG 解码失败: %w", err)
+		}
+		// RAW/HEIC 本身没有 IFD0 方向标签，方向信息来自转换器保留的内嵌缩略图 EXIF。
+		return &decodeResult{img: img, format: formatJPEG, exif: exif, metaSegments: metaSegments}, nil
+
+	default: // formatJPEG
+		metaSegments := extractMetaSegments(origBytes)
+		exif := exifFromSegments(metaSegments)
+		img, _, err := image.Decode(bytes.NewReader(origBytes))
+		if err != nil {
+			return nil, fmt.Errorf("解码失败: %w", err)
+		}
+		return &decodeResult{img: img, format: formatJPEG, exif: exif, metaSegments: metaSegments}, nil
+	}
+}
+
+// convertRawToJPEG 调用配置的外部转换器（libraw 的 dcraw_emu / heif-convert 等）
+// 把 RAW 或 HEIC 文件转换成中间 JPEG，转换器需要支持 "-o <输出路径>" 参数约定。
+func convertRawToJPEG(path string, cfg Config) ([]byte, error) {
+	if cfg.RawConverterCmd == "" {
+		return nil, errRawConverterNotConfigured
+	}
+
+	tmpOut, err := ioutil.TempFile("", "yasuo-raw-*.jpg")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpOut.Close()
+	defer os.Remove(tmpOut.Name())
+
+	cmd := exec.Command(cfg.RawConverterCmd, path, "-o", tmpOut.Name())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("外部转换器执行失败: %w (%s)", err, string(out))
+	}
+
+	return ioutil.ReadFile(tmpOut.Name())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestSniffFormat_ByExtension(t *testing.T) {
+	cases := []struct {
+		name string
+		want sourceFormat
+	}{
+		{"photo.png", formatPNG},
+		{"photo.PNG", formatPNG},
+		{"photo.webp", formatWebP},
+		{"photo.jpg", formatJPEG},
+		{"photo.JPEG", formatJPEG},
+		{"photo.heic", formatRawOrHEIC},
+		{"photo.cr2", formatRawOrHEIC},
+	}
+	for _, c := range cases {
+		if got := sniffFormat(c.name, nil); got != c.want {
+			t.Errorf("sniffFormat(%q, nil) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSniffFormat_MagicByteFallbackForUnknownExtension(t *testing.T) {
+	pngHead := append(append([]byte{}, pngSignature...), make([]byte, 8)...)
+	if got := sniffFormat("mystery.bin", pngHead); got != formatPNG {
+		t.Errorf("sniffFormat with PNG signature = %v, want formatPNG", got)
+	}
+
+	webpHead := make([]byte, 12)
+	copy(webpHead[0:4], "RIFF")
+	copy(webpHead[8:12], "WEBP")
+	if got := sniffFormat("mystery.bin", webpHead); got != formatWebP {
+		t.Errorf("sniffFormat with RIFF/WEBP header = %v, want formatWebP", got)
+	}
+
+	if got := sniffFormat("mystery.bin", []byte{0x00, 0x01, 0x02}); got != formatJPEG {
+		t.Errorf("sniffFormat with no recognizable header = %v, want formatJPEG (默认兜底)", got)
+	}
+}
+
+func TestOutputRelPath(t *testing.T) {
+	cases := []struct {
+		relPath string
+		format  sourceFormat
+		want    string
+	}{
+		{"a/b/photo.png", formatPNG, "a/b/photo.png"},
+		{"photo.jpg", formatJPEG, "photo.jpg"},
+		{"photo.JPEG", formatJPEG, "photo.JPEG"},
+		{"photo.webp", formatJPEG, "photo.jpg"},
+		{"photo.heic", formatJPEG, "photo.jpg"},
+		{"a/b/photo.cr2", formatJPEG, "a/b/photo.jpg"},
+	}
+	for _, c := range cases {
+		if got := outputRelPath(c.relPath, c.format); got != c.want {
+			t.Errorf("outputRelPath(%q, %v) = %q, want %q", c.relPath, c.format, got, c.want)
+		}
+	}
+}
+
+// TestPNGChunkRoundTrip 模拟流水线的真实路径：从一张带 tEXt 辅助块的源 PNG
+// 提取辅助块，重新编码图像（辅助块会被 image/png 丢弃），再插回去，
+// 确认辅助块的类型和内容原样保留。
+func TestPNGChunkRoundTrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 50), G: uint8(y * 50), B: 10, A: 255})
+		}
+	}
+	var base bytes.Buffer
+	if err := png.Encode(&base, img); err != nil {
+		t.Fatalf("编码基础 PNG 失败: %v", err)
+	}
+
+	textChunk := pngChunk{data: []byte("Comment\x00hello from a test")}
+	copy(textChunk.typ[:], "tEXt")
+	source := injectPNGChunks(base.Bytes(), []pngChunk{textChunk})
+
+	chunks := extractPNGChunks(source)
+	if len(chunks) != 1 || string(chunks[0].typ[:]) != "tEXt" {
+		t.Fatalf("extractPNGChunks 未能从源文件中找回 tEXt 块: %+v", chunks)
+	}
+	if !bytes.Equal(chunks[0].data, textChunk.data) {
+		t.Fatalf("提取出的 tEXt 内容 = %q, want %q", chunks[0].data, textChunk.data)
+	}
+
+	// 模拟流水线：重新编码同一张图（辅助块不会被带过来），再插回提取到的块。
+	var reencoded bytes.Buffer
+	if err := png.Encode(&reencoded, img); err != nil {
+		t.Fatalf("重新编码 PNG 失败: %v", err)
+	}
+	result := injectPNGChunks(reencoded.Bytes(), chunks)
+
+	final := extractPNGChunks(result)
+	if len(final) != 1 || !bytes.Equal(final[0].data, textChunk.data) {
+		t.Fatalf("往返之后 tEXt 块丢失或被改写: %+v", final)
+	}
+
+	if _, err := png.Decode(bytes.NewReader(result)); err != nil {
+		t.Fatalf("插回辅助块之后的 PNG 应当仍然可以正常解码: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Exif 保存一张 JPEG 图片里我们关心的 EXIF 信息，
+// 以及原始段数据（用于原样写回输出文件）。
+type Exif struct {
+	Raw []byte // APP1 原始内容（不含 "Exif\0\0" 之后的 TIFF 头之前的内容，含 Exif\0\0 头）
+
+	byteOrder binary.ByteOrder
+	ifd0      map[uint16]ifdEntry
+	exifIFD   map[uint16]ifdEntry
+	gpsIFD    map[uint16]ifdEntry
+}
+
+type ifdEntry struct {
+	tag       uint16
+	typ       uint16
+	count     uint32
+	valueOrOf []byte // 值本身（<=4字节）或偏移量的原始4字节表示
+}
+
+var errNotExif = errors.New("exif: 不是有效的 Exif 数据")
+
+const (
+	tagOrientation      = 0x0112
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFDPointer   = 0x8769
+	tagGPSIFDPointer    = 0x8825
+	tagDateTimeOriginal = 0x9003
+)
+
+// ParseExif 解析 APP1 段中 "Exif\0\0" 之后的 TIFF 数据，
+// 读出 IFD0、Exif 子 IFD 与 GPS 子 IFD 的全部条目。
+func ParseExif(app1 []byte) (*Exif, error) {
+	if len(app1) < 6 || string(app1[:6]) != "Exif\x00\x00" {
+		return nil, errNotExif
+	}
+	tiff := app1[6:]
+	if len(tiff) < 8 {
+		return nil, errNotExif
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errNotExif
+	}
+	if order.Uint16(tiff[2:4]) != 0x002A {
+		return nil, errNotExif
+	}
+
+	ifd0Offset := order.Uint32(tiff[4:8])
+	ifd0, next, err := readIFD(tiff, ifd0Offset, order)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Exif{
+		Raw:       app1,
+		byteOrder: order,
+		ifd0:      ifd0,
+	}
+	_ = next // IFD1（缩略图）目前不需要
+
+	if entry, ok := ifd0[tagExifIFDPointer]; ok {
+		off := order.Uint32(entry.valueOrOf)
+		if ifd, _, err := readIFD(tiff, off, order); err == nil {
+			e.exifIFD = ifd
+		}
+	}
+	if entry, ok := ifd0[tagGPSIFDPointer]; ok {
+		off := order.Uint32(entry.valueOrOf)
+		if ifd, _, err := readIFD(tiff, off, order); err == nil {
+			e.gpsIFD = ifd
+		}
+	}
+
+	return e, nil
+}
+
+// readIFD 从 tiff 缓冲区 offset 处读取一个 IFD，返回条目表及下一个 IFD 的偏移量。
+func readIFD(tiff []byte, offset uint32, order binary.ByteOrder) (map[uint16]ifdEntry, uint32, error) {
+	if int(offset)+2 > len(tiff) {
+		return nil, 0, errNotExif
+	}
+	count := order.Uint16(tiff[offset : offset+2])
+	entries := make(map[uint16]ifdEntry, count)
+
+	base := int(offset) + 2
+	for i := 0; i < int(count); i++ {
+		off := base + i*12
+		if off+12 > len(tiff) {
+			return nil, 0, errNotExif
+		}
+		entry := ifdEntry{
+			tag:       order.Uint16(tiff[off : off+2]),
+			typ:       order.Uint16(tiff[off+2 : off+4]),
+			count:     order.Uint32(tiff[off+4 : off+8]),
+			valueOrOf: tiff[off+8 : off+12],
+		}
+		entries[entry.tag] = entry
+	}
+
+	nextOff := base + int(count)*12
+	var next uint32
+	if nextOff+4 <= len(tiff) {
+		next = order.Uint32(tiff[nextOff : nextOff+4])
+	}
+	return entries, next, nil
+}
+
+// Orientation 返回 IFD0 中的方向标签（1-8），没有时默认为 1。
+func (e *Exif) Orientation() int {
+	if e == nil {
+		return 1
+	}
+	entry, ok := e.ifd0[tagOrientation]
+	if !ok || entry.typ != 3 { // SHORT
+		return 1
+	}
+	v := e.byteOrder.Uint16(entry.valueOrOf[:2])
+	if v < 1 || v > 8 {
+		return 1
+	}
+	return int(v)
+}
+
+// SetOrientation 原地改写方向标签的值。调用方在用 Orientation() 把像素物理
+// 旋转到正确方向之后应该调用它（通常传 1），否则下游的 EXIF 阅读器会拿残留的
+// 旧方向值再转一次。SHORT 类型的值内联存放在 IFD 条目里，直接改写不需要挪动
+// 或扩容任何数据；没有方向标签（或类型不是 SHORT）时什么也不做。
+func (e *Exif) SetOrientation(v int) {
+	if e == nil {
+		return
+	}
+	entry, ok := e.ifd0[tagOrientation]
+	if !ok || entry.typ != 3 {
+		return
+	}
+	e.byteOrder.PutUint16(entry.valueOrOf[:2], uint16(v))
+}
+
+// Make 返回相机厂商字符串（标签 0x010F），读取失败时返回空字符串。
+func (e *Exif) Make() string {
+	return e.asciiTag(e.ifd0, tagMake)
+}
+
+// Model 返回相机型号字符串（标签 0x0110）。
+func (e *Exif) Model() string {
+	return e.asciiTag(e.ifd0, tagModel)
+}
+
+// DateTimeOriginal 解析 Exif 子 IFD 中的拍摄时间（标签 0x9003），
+// 格式为 "2006:01:02 15:04:05"，解析失败返回零值。
+func (e *Exif) DateTimeOriginal() time.Time {
+	if e == nil || e.exifIFD == nil {
+		return time.Time{}
+	}
+	s := e.asciiTag(e.exifIFD, tagDateTimeOriginal)
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// HasGPS 表示该 EXIF 是否包含 GPS 子 IFD。
+func (e *Exif) HasGPS() bool {
+	return e != nil && len(e.gpsIFD) > 0
+}
+
+// asciiTag 读取类型为 ASCII(2) 的标签值，支持内联（<=4字节）和偏移量两种存放方式。
+func (e *Exif) asciiTag(ifd map[uint16]ifdEntry, tag uint16) string {
+	if ifd == nil {
+		return ""
+	}
+	entry, ok := ifd[tag]
+	if !ok || entry.typ != 2 {
+		return ""
+	}
+	var raw []byte
+	if entry.count <= 4 {
+		raw = entry.valueOrOf[:entry.count]
+	} else {
+		off := e.byteOrder.Uint32(entry.valueOrOf)
+		tiff := e.Raw[6:]
+		end := int(off) + int(entry.count)
+		if end > len(tiff) {
+			return ""
+		}
+		raw = tiff[int(off):end]
+	}
+	return string(bytes.TrimRight(raw, "\x00"))
+}
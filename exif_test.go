@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// TIFF buffer builder (either byte order, per its order field) used to assemble synthetic
+// Exif payloads byte-by-byte, patching offset fields once the full layout
+// (and therefore every absolute offset) is known.
+type tiffBuilder struct {
+	order binary.ByteOrder
+	buf   []byte
+}
+
+func (b *tiffBuilder) u16(v uint16) {
+	var tmp [2]byte
+	b.order.PutUint16(tmp[:], v)
+	b.buf = append(b.buf, tmp[:]...)
+}
+
+func (b *tiffBuilder) u32(v uint32) int {
+	pos := len(b.buf)
+	var tmp [4]byte
+	b.order.PutUint32(tmp[:], v)
+	b.buf = append(b.buf, tmp[:]...)
+	return pos
+}
+
+func (b *tiffBuilder) bytes(v []byte) {
+	b.buf = append(b.buf, v...)
+}
+
+func (b *tiffBuilder) patchU32(pos int, v uint32) {
+	b.order.PutUint32(b.buf[pos:pos+4], v)
+}
+
+func (b *tiffBuilder) len() int {
+	return len(b.buf)
+}
+
+// shortEntry appends a SHORT-valued IFD entry (value packed into the first
+// two bytes of the inline value/offset field, per the TIFF spec).
+func (b *tiffBuilder) shortEntry(tag uint16, v uint16) {
+	b.u16(tag)
+	b.u16(3) // SHORT
+	b.u32(1)
+	b.u16(v)
+	b.u16(0)
+}
+
+// longEntry appends a LONG-valued IFD entry and returns the buffer position
+// of its 4-byte value so the caller can patch in a real offset later.
+func (b *tiffBuilder) longEntry(tag uint16) int {
+	b.u16(tag)
+	b.u16(4) // LONG
+	b.u32(1)
+	return b.u32(0)
+}
+
+// asciiEntryInline appends an ASCII entry whose value fits inline (<=4 bytes).
+func (b *tiffBuilder) asciiEntryInline(tag uint16, s string) {
+	raw := append([]byte(s), 0)
+	if len(raw) > 4 {
+		panic("asciiEntryInline: value too long for inline storage")
+	}
+	b.u16(tag)
+	b.u16(2) // ASCII
+	b.u32(uint32(len(raw)))
+	var inline [4]byte
+	copy(inline[:], raw)
+	b.buf = append(b.buf, inline[:]...)
+}
+
+// asciiEntryOffset appends an ASCII entry whose value is too long to be
+// inlined; it returns the position of the 4-byte offset field to patch.
+func (b *tiffBuilder) asciiEntryOffset(tag uint16, s string) (valuePos int, raw []byte) {
+	raw = append([]byte(s), 0)
+	b.u16(tag)
+	b.u16(2) // ASCII
+	b.u32(uint32(len(raw)))
+	valuePos = b.u32(0)
+	return valuePos, raw
+}
+
+func TestParseExif_OrientationBothByteOrders(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		b := &tiffBuilder{order: order}
+		if order == binary.LittleEndian {
+			b.bytes([]byte("II"))
+		} else {
+			b.bytes([]byte("MM"))
+		}
+		b.u16(0x002A)
+		b.u32(8) // IFD0 offset
+
+		b.u16(1) // 1 entry
+		b.shortEntry(tagOrientation, 6)
+		b.u32(0) // next IFD offset
+
+		app1 := append([]byte("Exif\x00\x00"), b.buf...)
+		exif, err := ParseExif(app1)
+		if err != nil {
+			t.Fatalf("ParseExif failed for byte order %v: %v", order, err)
+		}
+		if got := exif.Orientation(); got != 6 {
+			t.Errorf("Orientation() = %d, want 6 (byte order %v)", got, order)
+		}
+	}
+}
+
+func TestParseExif_DefaultOrientationWhenMissing(t *testing.T) {
+	order := binary.LittleEndian
+	b := &tiffBuilder{order: order}
+	b.bytes([]byte("II"))
+	b.u16(0x002A)
+	b.u32(8)
+	b.u16(0) // no entries
+	b.u32(0)
+
+	app1 := append([]byte("Exif\x00\x00"), b.buf...)
+	exif, err := ParseExif(app1)
+	if err != nil {
+		t.Fatalf("ParseExif failed: %v", err)
+	}
+	if got := exif.Orientation(); got != 1 {
+		t.Errorf("Orientation() = %d, want default 1", got)
+	}
+}
+
+func TestParseExif_MakeModelAndDateTimeOriginal(t *testing.T) {
+	order := binary.LittleEndian
+	b := &tiffBuilder{order: order}
+	b.bytes([]byte("II"))
+	b.u16(0x002A)
+	b.u32(8)
+
+	b.u16(4) // Orientation, Make(inline), Model(offset-based), ExifIFDPointer
+	b.shortEntry(tagOrientation, 3)
+	b.asciiEntryInline(tagMake, "Co") // 3 bytes incl. NUL, fits inline
+	modelPos, modelRaw := b.asciiEntryOffset(tagModel, "Somelongcameramodelname")
+	exifPtrPos := b.longEntry(tagExifIFDPointer)
+	b.u32(0) // next IFD offset
+
+	modelOffset := b.len()
+	b.bytes(modelRaw)
+	b.patchU32(modelPos, uint32(modelOffset))
+
+	exifIFDOffset := b.len()
+	b.patchU32(exifPtrPos, uint32(exifIFDOffset))
+
+	b.u16(1) // DateTimeOriginal(offset-based)
+	dtPos, dtRaw := b.asciiEntryOffset(tagDateTimeOriginal, "2024:01:02 03:04:05")
+	b.u32(0) // next IFD offset (sub-IFD)
+
+	dtOffset := b.len()
+	b.bytes(dtRaw)
+	b.patchU32(dtPos, uint32(dtOffset))
+
+	app1 := append([]byte("Exif\x00\x00"), b.buf...)
+	exif, err := ParseExif(app1)
+	if err != nil {
+		t.Fatalf("ParseExif failed: %v", err)
+	}
+	if got := exif.Orientation(); got != 3 {
+		t.Errorf("Orientation() = %d, want 3", got)
+	}
+	if got := exif.Make(); got != "Co" {
+		t.Errorf("Make() = %q, want %q", got, "Co")
+	}
+	if got := exif.Model(); got != "Somelongcameramodelname" {
+		t.Errorf("Model() = %q, want %q", got, "Somelongcameramodelname")
+	}
+	want := "2024:01:02 03:04:05"
+	got := exif.DateTimeOriginal()
+	if got.Format("2006:01:02 15:04:05") != want {
+		t.Errorf("DateTimeOriginal() = %v, want %v", got, want)
+	}
+	if exif.HasGPS() {
+		t.Errorf("HasGPS() = true, want false (no GPS IFD present)")
+	}
+}
+
+func TestParseExif_HasGPS(t *testing.T) {
+	order := binary.LittleEndian
+	b := &tiffBuilder{order: order}
+	b.bytes([]byte("II"))
+	b.u16(0x002A)
+	b.u32(8)
+
+	b.u16(1) // GPSIFDPointer only
+	gpsPtrPos := b.longEntry(tagGPSIFDPointer)
+	b.u32(0) // next IFD offset
+
+	gpsIFDOffset := b.len()
+	b.patchU32(gpsPtrPos, uint32(gpsIFDOffset))
+
+	b.u16(1) // GPSVersionID, just enough for a non-empty GPS IFD
+	b.shortEntry(0x0000, 2)
+	b.u32(0) // next IFD offset
+
+	app1 := append([]byte("Exif\x00\x00"), b.buf...)
+	exif, err := ParseExif(app1)
+	if err != nil {
+		t.Fatalf("ParseExif failed: %v", err)
+	}
+	if !exif.HasGPS() {
+		t.Errorf("HasGPS() = false, want true")
+	}
+}
+
+func TestParseExif_RejectsNonExifData(t *testing.T) {
+	if _, err := ParseExif([]byte("not exif data at all")); err == nil {
+		t.Errorf("ParseExif: expected error for non-Exif input, got nil")
+	}
+}
+
+func TestExif_NilReceiverDefaults(t *testing.T) {
+	var exif *Exif
+	if got := exif.Orientation(); got != 1 {
+		t.Errorf("nil Exif Orientation() = %d, want 1", got)
+	}
+	if exif.HasGPS() {
+		t.Errorf("nil Exif HasGPS() = true, want false")
+	}
+}
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"image"
-	"image/jpeg"
 	"io/fs"
 	"io/ioutil"
 	"os"
@@ -17,29 +15,97 @@ type Config struct {
 	InputDir       string `json:"InputDir"`
 	OutputDir      string `json:"OutputDir"`
 	InitialQuality int    `json:"InitialQuality"`
+	Workers        int    `json:"Workers"` // 并发 worker 数，<=0 时默认 runtime.NumCPU()
+
+	MaxWidth  int    `json:"MaxWidth"`
+	MaxHeight int    `json:"MaxHeight"`
+	// ResizeMode 为 "fit"、"thumbnail"、"resize" 或 "none"（默认），见 resize.go
+	ResizeMode string `json:"ResizeMode"`
+
+	// RawConverterCmd 是可选的外部 RAW/HEIC 转换器（如 libraw 的 dcraw_emu、heif-convert），
+	// 为空时直接跳过 RAW/HEIC 输入文件（计入 Report.Skipped，不计入 Failed），见 decoders.go
+	RawConverterCmd string `json:"RawConverterCmd"`
+
+	// TargetMaxKB 和 MinSSIM 二选一，设置后以自适应质量搜索替代 InitialQuality，见 quality.go
+	TargetMaxKB int     `json:"TargetMaxKB"`
+	MinSSIM     float64 `json:"MinSSIM"`
 }
 
-// 从 JPEG 文件中提取 APP1(EXIF) 段
-func extractExif(data []byte) []byte {
+// metaSegment 是一个需要原样保留到输出文件的 JPEG 标记段
+// （APP1/Exif、APP1/XMP、APP2/ICC、APP13/IPTC）。
+type metaSegment struct {
+	marker byte   // 例如 0xE1
+	data   []byte // 段内容，不含 0xFFxx 标记和长度字段
+}
+
+const (
+	xmpHeader = "http://ns.adobe.com/xap/1.0/\x00"
+)
+
+// extractMetaSegments 遍历 JPEG 的标记段，收集 Exif、XMP、ICC 颜色档案
+// 与 IPTC 说明等需要透传到输出文件的段，保持原有出现顺序。
+func extractMetaSegments(data []byte) []metaSegment {
 	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
 		return nil
 	}
 
+	var segments []metaSegment
 	offset := 2
-	for offset+4 < len(data) {
+	for offset+4 <= len(data) {
 		if data[offset] != 0xFF {
 			break
 		}
 		marker := data[offset+1]
+		if marker == 0xDA { // SOS：压缩数据开始，标记段结束
+			break
+		}
 		size := int(data[offset+2])<<8 | int(data[offset+3])
-		if marker == 0xE1 { // APP1
-			return data[offset+4 : offset+2+size]
+		if offset+2+size > len(data) {
+			break
+		}
+		content := data[offset+4 : offset+2+size]
+
+		switch marker {
+		case 0xE1: // APP1：Exif 或 XMP
+			segments = append(segments, metaSegment{marker: marker, data: content})
+		case 0xE2: // APP2：ICC 颜色档案
+			segments = append(segments, metaSegment{marker: marker, data: content})
+		case 0xED: // APP13：IPTC/Photoshop
+			segments = append(segments, metaSegment{marker: marker, data: content})
 		}
 		offset += 2 + size
 	}
+	return segments
+}
+
+// exifFromSegments 从已提取的标记段中找到 Exif 段（区别于同为 APP1 的 XMP 段）并解析。
+func exifFromSegments(segments []metaSegment) *Exif {
+	for _, seg := range segments {
+		if seg.marker != 0xE1 {
+			continue
+		}
+		if strings.HasPrefix(string(seg.data), xmpHeader) {
+			continue
+		}
+		exif, err := ParseExif(seg.data)
+		if err != nil {
+			continue
+		}
+		return exif
+	}
 	return nil
 }
 
+// writeMetaSegments 把收集到的标记段写回输出文件，紧跟在 SOI 之后。
+func writeMetaSegments(w *os.File, segments []metaSegment) {
+	for _, seg := range segments {
+		length := uint16(len(seg.data) + 2)
+		w.Write([]byte{0xFF, seg.marker})
+		w.Write([]byte{byte(length >> 8), byte(length & 0xFF)})
+		w.Write(seg.data)
+	}
+}
+
 // 修正方向
 func fixOrientation(img image.Image, orientation int) image.Image {
 	switch orientation {
@@ -132,82 +198,55 @@ func main() {
 
 	os.MkdirAll(cfg.OutputDir, os.ModePerm)
 
-	fmt.Println("开始压缩并保留 EXIF...")
-
-	filepath.Walk(cfg.InputDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
+	jobs := collectJobs(cfg.InputDir)
+	if len(jobs) == 0 {
+		fmt.Println("未找到任何支持的图片文件")
+		return
+	}
 
-		lower := strings.ToLower(info.Name())
-		if !strings.HasSuffix(lower, ".jpg") && !strings.HasSuffix(lower, ".jpeg") {
-			return nil
-		}
+	fmt.Printf("开始压缩并保留 EXIF，共 %d 个文件...\n", len(jobs))
 
-		// 读取原始文件字节
-		origBytes, err := ioutil.ReadFile(path)
-		if err != nil {
-			fmt.Println("读取文件失败:", path)
-			return nil
-		}
+	report := runPipeline(cfg, jobs)
+	printReport(report)
 
-		// 提取 EXIF
-		exifBytes := extractExif(origBytes)
+	fmt.Println("按回车退出...")
+	fmt.Scanln()
+}
 
-		// 解码图片
-		imgFile, err := os.Open(path)
-		if err != nil {
-			fmt.Println("打开文件失败:", path)
+// collectJobs 遍历输入目录，收集所有受支持格式的文件（见 supportedExt），
+// relPath 保留相对 InputDir 的子目录结构，写输出时按实际编码格式重写扩展名。
+func collectJobs(inputDir string) []job {
+	var jobs []job
+	filepath.Walk(inputDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
 			return nil
 		}
-		img, _, err := image.Decode(imgFile)
-		imgFile.Close()
-		if err != nil {
-			fmt.Println("解码失败:", path)
+		if !supportedExt(strings.ToLower(filepath.Ext(info.Name()))) {
 			return nil
 		}
-
-		// 修正方向
-		orientation := 1
-		if len(exifBytes) >= 2 {
-			// 简单尝试解析 Orientation 字段
-			orientation = int(exifBytes[len(exifBytes)-1])
-		}
-		img = fixOrientation(img, orientation)
-
-		// 压缩到内存
-		var buf bytes.Buffer
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: cfg.InitialQuality})
+		rel, err := filepath.Rel(inputDir, path)
 		if err != nil {
-			fmt.Println("压缩失败:", path)
-			return nil
+			rel = info.Name()
 		}
-
-		// 写入输出文件
-		outPath := filepath.Join(cfg.OutputDir, info.Name())
-		outFile, err := os.Create(outPath)
-		if err != nil {
-			fmt.Println("创建输出文件失败:", outPath)
-			return nil
-		}
-		defer outFile.Close()
-
-		if exifBytes != nil {
-			outFile.Write([]byte{0xFF, 0xD8})           // SOI
-			outFile.Write([]byte{0xFF, 0xE1})           // APP1
-			length := uint16(len(exifBytes) + 2)
-			outFile.Write([]byte{byte(length >> 8), byte(length & 0xFF)})
-			outFile.Write(exifBytes)
-			outFile.Write(buf.Bytes()[2:]) // 跳过原 JPEG SOI
-		} else {
-			outFile.Write(buf.Bytes())
-		}
-
-		fmt.Println("压缩成功:", outPath)
+		jobs = append(jobs, job{path: path, relPath: rel})
 		return nil
 	})
+	return jobs
+}
 
-	fmt.Println("完成")
-	fmt.Println("按回车退出...")
-	fmt.Scanln()
+// printReport 打印本次批处理的汇总结果。
+func printReport(r Report) {
+	if r.Cancelled {
+		fmt.Println("已取消，部分文件未处理")
+	}
+	fmt.Printf("完成: 成功 %d, 失败 %d, 跳过 %d (共 %d)\n", r.Succeeded, r.Failed, r.Skipped, r.Total)
+	if r.BytesBefore > 0 {
+		fmt.Printf("体积: %.2fMB -> %.2fMB (节省 %.1f%%)\n",
+			float64(r.BytesBefore)/1024/1024,
+			float64(r.BytesAfter)/1024/1024,
+			100*(1-float64(r.BytesAfter)/float64(r.BytesBefore)))
+	}
+	for _, e := range r.Errors {
+		fmt.Println("  错误:", e)
+	}
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// job 描述一个待处理的文件。
+type job struct {
+	path    string
+	relPath string
+}
+
+// jobResult 是单个文件处理完成后的结果，用于汇总统计与报告。
+type jobResult struct {
+	path     string
+	err      error
+	skipped  bool // 预期中的跳过（如未配置 RawConverterCmd），不计入 err/Failed
+	origSize int64
+	newSize  int64
+}
+
+// Report 是一批文件处理完后的汇总报告。
+type Report struct {
+	Total       int
+	Succeeded   int
+	Failed      int
+	Skipped     int // 预期中被跳过的文件数（如未配置 RAW/HEIC 转换器），不算失败
+	BytesBefore int64
+	BytesAfter  int64
+	Errors      []string
+	Cancelled   bool
+}
+
+// runPipeline 用一个固定大小的 worker 池并发处理 jobs，
+// 一边处理一边打印进度行，并在收到 SIGINT 时优雅取消：
+// 正在写入的输出文件会被清理掉，已完成的文件保持不变。
+func runPipeline(cfg Config, jobs []job) Report {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			fmt.Println("\n收到中断信号，正在取消剩余任务...")
+			cancel()
+		}
+	}()
+	defer signal.Stop(sigCh)
+
+	jobCh := make(chan job)
+	resultCh := make(chan jobResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				resultCh <- processJob(ctx, cfg, j)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobCh)
+		for _, j := range jobs {
+			select {
+			case jobCh <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	report := Report{Total: len(jobs)}
+	start := time.Now()
+	done := 0
+	for res := range resultCh {
+		done++
+		switch {
+		case res.skipped:
+			report.Skipped++
+		case res.err != nil:
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", res.path, res.err))
+		default:
+			report.Succeeded++
+			report.BytesBefore += res.origSize
+			report.BytesAfter += res.newSize
+		}
+		printProgress(done, report.Total, report.BytesBefore-report.BytesAfter, start)
+	}
+	fmt.Println()
+
+	if ctx.Err() != nil {
+		report.Cancelled = true
+	}
+	return report
+}
+
+// printProgress 打印一行可刷新的进度：完成数/总数、已节省字节数、预计剩余时间。
+func printProgress(done, total int, bytesSaved int64, start time.Time) {
+	elapsed := time.Since(start)
+	var eta time.Duration
+	if done > 0 {
+		perFile := elapsed / time.Duration(done)
+		eta = perFile * time.Duration(total-done)
+	}
+	fmt.Printf("\r进度: %d/%d  已节省: %.2fMB  预计剩余: %s   ",
+		done, total, float64(bytesSaved)/1024/1024, eta.Round(time.Second))
+}
+
+// processJob 处理单个文件：解码、修正方向、压缩、写出。
+// 输出先写到临时文件再原子改名，取消时清理半成品临时文件。
+func processJob(ctx context.Context, cfg Config, j job) jobResult {
+	res := jobResult{path: j.path}
+
+	select {
+	case <-ctx.Done():
+		res.err = ctx.Err()
+		return res
+	default:
+	}
+
+	if info, err := os.Stat(j.path); err == nil {
+		res.origSize = info.Size()
+	}
+
+	decoded, err := decodeSource(j.path, cfg)
+	if err != nil {
+		if errors.Is(err, errRawConverterNotConfigured) {
+			res.skipped = true
+			return res
+		}
+		res.err = err
+		return res
+	}
+
+	img := fixOrientation(decoded.img, decoded.exif.Orientation())
+	img = applyResize(img, cfg)
+	normalizeOrientationMetadata(decoded)
+
+	var buf bytes.Buffer
+	switch decoded.format {
+	case formatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			res.err = fmt.Errorf("PNG 编码失败: %w", err)
+			return res
+		}
+		buf = *bytes.NewBuffer(injectPNGChunks(buf.Bytes(), decoded.pngChunks))
+	default:
+		if cfg.TargetMaxKB > 0 || cfg.MinSSIM > 0 {
+			encoded, _, err := findAdaptiveQuality(img, cfg)
+			if err != nil {
+				res.err = fmt.Errorf("自适应质量搜索失败: %w", err)
+				return res
+			}
+			buf = *bytes.NewBuffer(encoded)
+		} else if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: cfg.InitialQuality}); err != nil {
+			res.err = fmt.Errorf("压缩失败: %w", err)
+			return res
+		}
+	}
+
+	outPath := filepath.Join(cfg.OutputDir, outputRelPath(j.relPath, decoded.format))
+	tmpPath := outPath + ".tmp"
+	os.MkdirAll(filepath.Dir(outPath), os.ModePerm)
+
+	outFile, err := os.Create(tmpPath)
+	if err != nil {
+		res.err = fmt.Errorf("创建输出文件失败: %w", err)
+		return res
+	}
+
+	if decoded.format != formatPNG && len(decoded.metaSegments) > 0 {
+		outFile.Write([]byte{0xFF, 0xD8})
+		writeMetaSegments(outFile, decoded.metaSegments)
+		outFile.Write(buf.Bytes()[2:])
+	} else {
+		outFile.Write(buf.Bytes())
+	}
+	outFile.Close()
+
+	select {
+	case <-ctx.Done():
+		os.Remove(tmpPath)
+		res.err = ctx.Err()
+		return res
+	default:
+	}
+
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		os.Remove(tmpPath)
+		res.err = fmt.Errorf("重命名输出文件失败: %w", err)
+		return res
+	}
+
+	if info, err := os.Stat(outPath); err == nil {
+		res.newSize = info.Size()
+	}
+	return res
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestJPEG 在 dir 下生成一张有一定细节的小 JPEG，供流水线测试使用。
+func writeTestJPEG(t *testing.T, dir, name string) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 10), G: uint8(y * 10), B: 100, A: 255})
+		}
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("创建测试 JPEG 失败: %v", err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("编码测试 JPEG 失败: %v", err)
+	}
+	return path
+}
+
+func TestProcessJob_CanceledContext_LeavesNoOutput(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	srcPath := writeTestJPEG(t, inDir, "a.jpg")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // 调用前就已经取消
+
+	cfg := Config{OutputDir: outDir, InitialQuality: 80}
+	res := processJob(ctx, cfg, job{path: srcPath, relPath: "a.jpg"})
+
+	if res.err == nil {
+		t.Fatalf("已取消的 context 应当返回错误，却得到 nil")
+	}
+
+	outPath := filepath.Join(outDir, "a.jpg")
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("取消后不应留下输出文件，stat 结果: %v", err)
+	}
+	if _, err := os.Stat(outPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("取消后不应留下临时文件，stat 结果: %v", err)
+	}
+}
+
+func TestProcessJob_Success_NoLeftoverTmpFile(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	srcPath := writeTestJPEG(t, inDir, "b.jpg")
+
+	cfg := Config{OutputDir: outDir, InitialQuality: 80}
+	res := processJob(context.Background(), cfg, job{path: srcPath, relPath: "b.jpg"})
+	if res.err != nil {
+		t.Fatalf("processJob 失败: %v", res.err)
+	}
+
+	outPath := filepath.Join(outDir, "b.jpg")
+	if _, err := os.Stat(outPath); err != nil {
+		t.Fatalf("期望输出文件存在: %v", err)
+	}
+	if _, err := os.Stat(outPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("临时文件应当已被改名，不应残留: stat 结果 %v", err)
+	}
+}
+
+// TestRunPipeline_ReportTotalsAddUp 用一批成功/失败/跳过混合的任务驱动
+// runPipeline，校验并发聚合出来的 Report 各项总数互相吻合——这是并发 bug
+// （如 BytesBefore/BytesAfter 竞态）最容易藏身的地方。
+func TestRunPipeline_ReportTotalsAddUp(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	const n = 6
+	var jobs []job
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("img%d.jpg", i)
+		p := writeTestJPEG(t, inDir, name)
+		jobs = append(jobs, job{path: p, relPath: name})
+	}
+
+	// 一个必然失败的任务：源文件不存在。
+	jobs = append(jobs, job{path: filepath.Join(inDir, "missing.jpg"), relPath: "missing.jpg"})
+
+	// 一个必然被跳过的任务：RAW/HEIC 输入但没配置 RawConverterCmd。
+	heicPath := filepath.Join(inDir, "photo.heic")
+	if err := os.WriteFile(heicPath, []byte("not a real raw file"), 0644); err != nil {
+		t.Fatalf("写入 heic 占位文件失败: %v", err)
+	}
+	jobs = append(jobs, job{path: heicPath, relPath: "photo.heic"})
+
+	cfg := Config{OutputDir: outDir, InitialQuality: 80, Workers: 3}
+	report := runPipeline(cfg, jobs)
+
+	if report.Total != len(jobs) {
+		t.Fatalf("Total = %d, 期望 %d", report.Total, len(jobs))
+	}
+	if got := report.Succeeded + report.Failed + report.Skipped; got != report.Total {
+		t.Errorf("Succeeded(%d)+Failed(%d)+Skipped(%d) = %d，期望等于 Total = %d",
+			report.Succeeded, report.Failed, report.Skipped, got, report.Total)
+	}
+	if report.Succeeded != n {
+		t.Errorf("Succeeded = %d, 期望 %d", report.Succeeded, n)
+	}
+	if report.Failed != 1 {
+		t.Errorf("Failed = %d, 期望 1", report.Failed)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("Skipped = %d, 期望 1", report.Skipped)
+	}
+	if report.BytesBefore <= 0 || report.BytesAfter <= 0 {
+		t.Errorf("期望体积统计为正数，实际 before=%d after=%d", report.BytesBefore, report.BytesAfter)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// pngChunk 是一个原始 PNG 数据块（类型 + 内容），用于在重新编码时原样插回。
+type pngChunk struct {
+	typ  [4]byte
+	data []byte
+}
+
+// ancillary PNG 块类型：文本说明与内嵌 Exif，压缩/解码时容易被丢弃，需要单独保留。
+var preservedPNGChunkTypes = map[string]bool{
+	"tEXt": true,
+	"iTXt": true,
+	"eXIf": true,
+}
+
+// extractPNGChunks 遍历 PNG 数据块，收集 tEXt/iTXt/eXIf 等需要透传的辅助块。
+func extractPNGChunks(data []byte) []pngChunk {
+	if len(data) < 8 || !bytes.Equal(data[:8], pngSignature) {
+		return nil
+	}
+
+	var chunks []pngChunk
+	offset := 8
+	for offset+8 <= len(data) {
+		length := binary.BigEndian.Uint32(data[offset : offset+4])
+		var typ [4]byte
+		copy(typ[:], data[offset+4:offset+8])
+		dataStart := offset + 8
+		dataEnd := dataStart + int(length)
+		if dataEnd+4 > len(data) {
+			break
+		}
+
+		if preservedPNGChunkTypes[string(typ[:])] {
+			chunkData := make([]byte, length)
+			copy(chunkData, data[dataStart:dataEnd])
+			chunks = append(chunks, pngChunk{typ: typ, data: chunkData})
+		}
+
+		offset = dataEnd + 4 // 跳过 CRC
+		if string(typ[:]) == "IEND" {
+			break
+		}
+	}
+	return chunks
+}
+
+// injectPNGChunks 把保留下来的辅助块插回重新编码后的 PNG 数据，位置在 IEND 之前。
+func injectPNGChunks(encoded []byte, chunks []pngChunk) []byte {
+	if len(chunks) == 0 {
+		return encoded
+	}
+	iend := bytes.LastIndex(encoded, []byte("IEND"))
+	if iend < 4 {
+		return encoded
+	}
+	insertAt := iend - 4 // IEND 块的长度字段起始处
+
+	var buf bytes.Buffer
+	buf.Write(encoded[:insertAt])
+	for _, c := range chunks {
+		writePNGChunk(&buf, c)
+	}
+	buf.Write(encoded[insertAt:])
+	return buf.Bytes()
+}
+
+func writePNGChunk(buf *bytes.Buffer, c pngChunk) {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(c.data)))
+	buf.Write(lenBuf[:])
+
+	crcInput := append(append([]byte{}, c.typ[:]...), c.data...)
+	buf.Write(c.typ[:])
+	buf.Write(c.data)
+
+	var crcBuf [4]byte
+	binary.BigEndian.PutUint32(crcBuf[:], crc32.ChecksumIEEE(crcInput))
+	buf.Write(crcBuf[:])
+}
+
+// exifFromPNGChunks 在保留的 PNG 块中查找 eXIf 块并解析为 Exif。
+func exifFromPNGChunks(chunks []pngChunk) *Exif {
+	for _, c := range chunks {
+		if string(c.typ[:]) != "eXIf" {
+			continue
+		}
+		exif, err := ParseExif(append([]byte("Exif\x00\x00"), c.data...))
+		if err != nil {
+			continue
+		}
+		return exif
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+const (
+	qualityLo        = 40
+	qualityHi        = 95
+	qualityTolerance = 2 // 二分区间宽度（hi-lo）不大于该值时停止搜索
+)
+
+// luma 把图像转换成 [0,255] 范围的灰度（亮度）矩阵，用于 SSIM 计算。
+func luma(img image.Image) (data []float64, w, h int) {
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	data = make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			data[y*w+x] = float64(gray.Y)
+		}
+	}
+	return data, w, h
+}
+
+// ssim 用简化的 8x8 分块 SSIM 计算两幅等尺寸灰度图的结构相似度，
+// 逐块统计均值、方差与协方差后按标准 SSIM 公式打分，最后取所有块的平均值。
+func ssim(a, b []float64, w, h int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	var total float64
+	var windows int
+	for y0 := 0; y0 < h; y0 += 8 {
+		y1 := y0 + 8
+		if y1 > h {
+			y1 = h
+		}
+		for x0 := 0; x0 < w; x0 += 8 {
+			x1 := x0 + 8
+			if x1 > w {
+				x1 = w
+			}
+
+			var meanA, meanB float64
+			n := float64((y1 - y0) * (x1 - x0))
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					meanA += a[y*w+x]
+					meanB += b[y*w+x]
+				}
+			}
+			meanA /= n
+			meanB /= n
+
+			var varA, varB, cov float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					da := a[y*w+x] - meanA
+					db := b[y*w+x] - meanB
+					varA += da * da
+					varB += db * db
+					cov += da * db
+				}
+			}
+			varA /= n
+			varB /= n
+			cov /= n
+
+			num := (2*meanA*meanB + c1) * (2*cov + c2)
+			den := (meanA*meanA + meanB*meanB + c1) * (varA + varB + c2)
+			total += num / den
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// encodeAtQuality 在给定的 JPEG 质量下编码图像。
+func encodeAtQuality(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ssimAgainstLuma 重新解码 encoded 并与原图的亮度矩阵比较，返回两者的 SSIM。
+// origLuma 由调用方预先计算好并在多次二分迭代间复用，避免重复转换原图。
+func ssimAgainstLuma(origLuma []float64, w, h int, encoded []byte) (float64, error) {
+	decoded, err := jpeg.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		return 0, err
+	}
+	b, _, _ := luma(decoded)
+	return ssim(origLuma, b, w, h), nil
+}
+
+// findAdaptiveQuality 在 [qualityLo, qualityHi] 区间内二分搜索 JPEG 质量：
+//   - 设置了 TargetMaxKB 时，搜索体积不超过该值的最高质量（榨干体积预算）；
+//   - 设置了 MinSSIM 时，搜索仍满足该 SSIM 下限的最低质量（在保证观感的前提下尽量压小体积）。
+// 命中的那次编码结果会被缓存下来直接返回，避免二分结束后再多编码一次。
+func findAdaptiveQuality(img image.Image, cfg Config) (encoded []byte, quality int, err error) {
+	if cfg.TargetMaxKB <= 0 && cfg.MinSSIM <= 0 {
+		return nil, 0, errNoQualityTarget
+	}
+	lo, hi := qualityLo, qualityHi
+
+	var origLuma []float64
+	var origW, origH int
+	if cfg.TargetMaxKB <= 0 {
+		origLuma, origW, origH = luma(img)
+	}
+
+	// meetsBudget(q) 为 true 表示质量 q 仍在预算内（体积达标，或 SSIM 达标）。
+	meetsBudget := func(q int) (bool, []byte, error) {
+		buf, err := encodeAtQuality(img, q)
+		if err != nil {
+			return false, nil, err
+		}
+		if cfg.TargetMaxKB > 0 {
+			return len(buf) <= cfg.TargetMaxKB*1024, buf, nil
+		}
+		score, err := ssimAgainstLuma(origLuma, origW, origH, buf)
+		if err != nil {
+			return false, nil, err
+		}
+		return score >= cfg.MinSSIM, buf, nil
+	}
+
+	wantHighest := cfg.TargetMaxKB > 0 // 体积预算下要找最高质量，SSIM 下限下要找最低质量
+
+	var best []byte
+	bestQuality := -1
+	for hi-lo > qualityTolerance {
+		mid := (lo + hi) / 2
+		ok, buf, err := meetsBudget(mid)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			// mid 达标：记录下来作为目前最优候选，再往同方向收紧区间。
+			best, bestQuality = buf, mid
+		}
+		if ok {
+			if wantHighest {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		} else {
+			if wantHighest {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+	}
+
+	if best == nil {
+		// 没有任何一次迭代达标，直接用收敛后的边界兜底；此时返回的编码不一定满足约束。
+		q := hi
+		if wantHighest {
+			q = lo
+		}
+		buf, err := encodeAtQuality(img, q)
+		if err != nil {
+			return nil, 0, err
+		}
+		best, bestQuality = buf, q
+	}
+
+	return best, bestQuality, nil
+}
+
+var errNoQualityTarget = fmt.Errorf("quality: 既没有设置 TargetMaxKB 也没有设置 MinSSIM")
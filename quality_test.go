@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+func TestSSIM_IdenticalImagesScoreOne(t *testing.T) {
+	img := detailedTestImage(32, 32)
+	a, w, h := luma(img)
+	if got := ssim(a, a, w, h); math.Abs(got-1) > 1e-9 {
+		t.Errorf("ssim(a, a) = %v, want 1 for identical inputs", got)
+	}
+}
+
+func TestSSIM_DegradedImageScoresLower(t *testing.T) {
+	img := detailedTestImage(32, 32)
+	a, w, h := luma(img)
+
+	// 构造一个明显退化的版本：所有像素抹平成同一个灰度值。
+	flat := make([]float64, len(a))
+	for i := range flat {
+		flat[i] = 128
+	}
+
+	got := ssim(a, flat, w, h)
+	if got >= 0.99 {
+		t.Errorf("ssim(detailed, flat) = %v, want clearly below 1 for a heavily degraded image", got)
+	}
+}
+
+// detailedTestImage 生成一张有足够细节、压缩体积会随质量明显变化的测试图，
+// 纯色图在任何 JPEG 质量下都几乎同样小，无法用来验证体积/SSIM 搜索的行为。
+func detailedTestImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r := uint8((x * 7) ^ (y * 13))
+			g := uint8((x*x + y*3) % 256)
+			b := uint8((x + y*y) % 256)
+			img.SetRGBA(x, y, color.RGBA{R: r, G: g, B: b, A: 255})
+		}
+	}
+	return img
+}
+
+func TestFindAdaptiveQuality_TargetMaxKB(t *testing.T) {
+	img := detailedTestImage(96, 96)
+
+	// 先量出质量上下限各自的体积，选一个两者之间的预算，确保搜索确实有意义。
+	lowBuf, err := encodeAtQuality(img, qualityLo)
+	if err != nil {
+		t.Fatalf("encodeAtQuality(lo) failed: %v", err)
+	}
+	hiBuf, err := encodeAtQuality(img, qualityHi)
+	if err != nil {
+		t.Fatalf("encodeAtQuality(hi) failed: %v", err)
+	}
+	if len(hiBuf) <= len(lowBuf) {
+		t.Skip("test image did not produce a size/quality gradient; cannot validate budget search")
+	}
+	targetKB := (len(lowBuf)/1024 + len(hiBuf)/1024) / 2
+	if targetKB < 1 {
+		targetKB = 1
+	}
+
+	cfg := Config{TargetMaxKB: targetKB}
+	encoded, quality, err := findAdaptiveQuality(img, cfg)
+	if err != nil {
+		t.Fatalf("findAdaptiveQuality failed: %v", err)
+	}
+	if quality < qualityLo || quality > qualityHi {
+		t.Errorf("quality = %d, want within [%d,%d]", quality, qualityLo, qualityHi)
+	}
+	if len(encoded)/1024 > targetKB+1 { // +1 为 KB 取整留出误差
+		t.Errorf("encoded size = %dKB, want <= %dKB", len(encoded)/1024, targetKB)
+	}
+
+	// 返回的 buffer 必须确实就是在 quality 下编码出来的那一份，而不是别的质量。
+	reencoded, err := encodeAtQuality(img, quality)
+	if err != nil {
+		t.Fatalf("re-encoding at reported quality %d failed: %v", quality, err)
+	}
+	if !bytes.Equal(reencoded, encoded) {
+		t.Errorf("cached encoded buffer does not match a fresh encode at the reported quality %d", quality)
+	}
+}
+
+func TestFindAdaptiveQuality_MinSSIM(t *testing.T) {
+	img := detailedTestImage(96, 96)
+	const minSSIM = 0.9
+
+	cfg := Config{MinSSIM: minSSIM}
+	encoded, quality, err := findAdaptiveQuality(img, cfg)
+	if err != nil {
+		t.Fatalf("findAdaptiveQuality failed: %v", err)
+	}
+	if quality < qualityLo || quality > qualityHi {
+		t.Errorf("quality = %d, want within [%d,%d]", quality, qualityLo, qualityHi)
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("decoding returned buffer failed: %v", err)
+	}
+	a, w, h := luma(img)
+	b, _, _ := luma(decoded)
+	got := ssim(a, b, w, h)
+	if got < minSSIM {
+		t.Errorf("returned encode has ssim=%v, want >= MinSSIM=%v (the search must not return a candidate below the floor)", got, minSSIM)
+	}
+}
+
+func TestFindAdaptiveQuality_NoTargetReturnsError(t *testing.T) {
+	img := detailedTestImage(16, 16)
+	if _, _, err := findAdaptiveQuality(img, Config{}); err == nil {
+		t.Errorf("findAdaptiveQuality with neither TargetMaxKB nor MinSSIM set: want error, got nil")
+	}
+}
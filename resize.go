@@ -0,0 +1,227 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// lanczosA 是 Lanczos 核的支持半径（Lanczos-3）。
+const lanczosA = 3.0
+
+// sinc 是归一化 sinc 函数：sin(pi*x)/(pi*x)，x=0 时为 1。
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// lanczosKernel 计算 Lanczos-3 核在 x 处的权重，|x|>=3 时为 0。
+func lanczosKernel(x float64) float64 {
+	if x <= -lanczosA || x >= lanczosA {
+		return 0
+	}
+	return sinc(x) * sinc(x/lanczosA)
+}
+
+// resampleWeight 是某个输出像素对应的一个输入像素的下标与权重。
+type resampleWeight struct {
+	srcIndex int
+	weight   float64
+}
+
+// buildResampleWeights 为从 srcSize 缩放到 dstSize 的每个输出坐标，
+// 预计算落在 Lanczos-3 核支持范围内的源像素下标及权重（已按源边界夹紧并归一化）。
+func buildResampleWeights(srcSize, dstSize int) [][]resampleWeight {
+	table := make([][]resampleWeight, dstSize)
+	scale := float64(srcSize) / float64(dstSize)
+	// 缩小时核需要按比例放宽，避免混叠；放大时保持核宽度不变。
+	filterScale := scale
+	if filterScale < 1 {
+		filterScale = 1
+	}
+	radius := lanczosA * filterScale
+
+	for i := 0; i < dstSize; i++ {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := int(math.Floor(center - radius))
+		hi := int(math.Ceil(center + radius))
+
+		var weights []resampleWeight
+		var sum float64
+		for s := lo; s <= hi; s++ {
+			w := lanczosKernel((float64(s) - center) / filterScale)
+			if w == 0 {
+				continue
+			}
+			clamped := s
+			if clamped < 0 {
+				clamped = 0
+			} else if clamped >= srcSize {
+				clamped = srcSize - 1
+			}
+			weights = append(weights, resampleWeight{srcIndex: clamped, weight: w})
+			sum += w
+		}
+		if sum != 0 {
+			for j := range weights {
+				weights[j].weight /= sum
+			}
+		}
+		table[i] = weights
+	}
+	return table
+}
+
+// resizeLanczos 用可分离的 Lanczos-3 卷积把 img 缩放到 dstW x dstH：
+// 先沿水平方向卷积得到中间图像，再沿垂直方向卷积得到最终结果。
+func resizeLanczos(img image.Image, dstW, dstH int) *image.RGBA {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if dstW <= 0 || dstH <= 0 || srcW == 0 || srcH == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	for y := 0; y < srcH; y++ {
+		for x := 0; x < srcW; x++ {
+			src.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	colWeights := buildResampleWeights(srcW, dstW)
+	rowWeights := buildResampleWeights(srcH, dstH)
+
+	// 水平方向：srcW x srcH -> dstW x srcH
+	horiz := image.NewRGBA(image.Rect(0, 0, dstW, srcH))
+	for y := 0; y < srcH; y++ {
+		for x := 0; x < dstW; x++ {
+			var r, g, bl, a float64
+			for _, w := range colWeights[x] {
+				c := src.RGBAAt(w.srcIndex, y)
+				r += float64(c.R) * w.weight
+				g += float64(c.G) * w.weight
+				bl += float64(c.B) * w.weight
+				a += float64(c.A) * w.weight
+			}
+			horiz.SetRGBA(x, y, clampRGBA(r, g, bl, a))
+		}
+	}
+
+	// 垂直方向：dstW x srcH -> dstW x dstH
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for x := 0; x < dstW; x++ {
+		for y := 0; y < dstH; y++ {
+			var r, g, bl, a float64
+			for _, w := range rowWeights[y] {
+				c := horiz.RGBAAt(x, w.srcIndex)
+				r += float64(c.R) * w.weight
+				g += float64(c.G) * w.weight
+				bl += float64(c.B) * w.weight
+				a += float64(c.A) * w.weight
+			}
+			dst.SetRGBA(x, y, clampRGBA(r, g, bl, a))
+		}
+	}
+
+	return dst
+}
+
+// clampRGBA 把浮点卷积结果夹紧到 [0,255] 并转换为 color.RGBA。
+func clampRGBA(r, g, b, a float64) color.RGBA {
+	return color.RGBA{
+		R: clampByte(r),
+		G: clampByte(g),
+		B: clampByte(b),
+		A: clampByte(a),
+	}
+}
+
+func clampByte(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// applyResize 根据 Config 里的 ResizeMode 对 img 做缩放：
+//   - "none" / ""：原样返回
+//   - "fit"：按比例缩小到不超过 MaxWidth x MaxHeight（只缩小，不放大）
+//   - "resize"：直接缩放到 MaxWidth x MaxHeight（缺省的一边按原图比例推算）
+//   - "thumbnail"：先按比例放大/缩小覆盖 MaxWidth x MaxHeight，再居中裁剪到刚好相等
+func applyResize(img image.Image, cfg Config) image.Image {
+	switch cfg.ResizeMode {
+	case "fit":
+		return resizeFit(img, cfg.MaxWidth, cfg.MaxHeight)
+	case "resize":
+		return resizeExact(img, cfg.MaxWidth, cfg.MaxHeight)
+	case "thumbnail":
+		return resizeThumbnail(img, cfg.MaxWidth, cfg.MaxHeight)
+	default:
+		return img
+	}
+}
+
+func resizeFit(img image.Image, maxW, maxH int) image.Image {
+	if maxW <= 0 || maxH <= 0 {
+		return img
+	}
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if srcW <= maxW && srcH <= maxH {
+		return img // 只缩小，不放大
+	}
+	scale := math.Min(float64(maxW)/float64(srcW), float64(maxH)/float64(srcH))
+	dstW := int(math.Round(float64(srcW) * scale))
+	dstH := int(math.Round(float64(srcH) * scale))
+	return resizeLanczos(img, maxOf(dstW, 1), maxOf(dstH, 1))
+}
+
+func resizeExact(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if w <= 0 && h <= 0 {
+		return img
+	}
+	if w <= 0 {
+		w = int(math.Round(float64(srcW) * float64(h) / float64(srcH)))
+	}
+	if h <= 0 {
+		h = int(math.Round(float64(srcH) * float64(w) / float64(srcW)))
+	}
+	return resizeLanczos(img, maxOf(w, 1), maxOf(h, 1))
+}
+
+func resizeThumbnail(img image.Image, w, h int) image.Image {
+	if w <= 0 || h <= 0 {
+		return img
+	}
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	scale := math.Max(float64(w)/float64(srcW), float64(h)/float64(srcH))
+	coverW := maxOf(int(math.Round(float64(srcW)*scale)), w)
+	coverH := maxOf(int(math.Round(float64(srcH)*scale)), h)
+	covered := resizeLanczos(img, coverW, coverH)
+
+	x0 := (coverW - w) / 2
+	y0 := (coverH - h) / 2
+	cropped := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cropped.Set(x, y, covered.At(x0+x, y0+y))
+		}
+	}
+	return cropped
+}
+
+func maxOf(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+const weightSumTolerance = 1e-9
+
+func TestBuildResampleWeights_SumsToOne(t *testing.T) {
+	cases := []struct{ src, dst int }{
+		{100, 50}, // downscale
+		{50, 100}, // upscale
+		{64, 64},  // identity
+		{7, 3},    // odd, small
+		{3, 1},    // collapse to a single pixel
+	}
+	for _, c := range cases {
+		table := buildResampleWeights(c.src, c.dst)
+		if len(table) != c.dst {
+			t.Fatalf("src=%d dst=%d: got %d weight rows, want %d", c.src, c.dst, len(table), c.dst)
+		}
+		for i, weights := range table {
+			var sum float64
+			for _, w := range weights {
+				sum += w.weight
+			}
+			if math.Abs(sum-1) > weightSumTolerance {
+				t.Errorf("src=%d dst=%d index=%d: weights sum to %v, want 1", c.src, c.dst, i, sum)
+			}
+		}
+	}
+}
+
+func TestBuildResampleWeights_ClampsAtBorders(t *testing.T) {
+	table := buildResampleWeights(10, 40) // large upscale factor stresses the kernel radius
+	for i, weights := range table {
+		for _, w := range weights {
+			if w.srcIndex < 0 || w.srcIndex >= 10 {
+				t.Fatalf("index=%d: srcIndex=%d out of bounds [0,10)", i, w.srcIndex)
+			}
+		}
+	}
+}
+
+func TestLanczosKernel_ZeroOutsideSupport(t *testing.T) {
+	if v := lanczosKernel(lanczosA); v != 0 {
+		t.Errorf("lanczosKernel(%v) = %v, want 0 at the support boundary", lanczosA, v)
+	}
+	if v := lanczosKernel(lanczosA + 1); v != 0 {
+		t.Errorf("lanczosKernel(%v) = %v, want 0 beyond the support", lanczosA+1, v)
+	}
+	if v := lanczosKernel(0); v != 1 {
+		t.Errorf("lanczosKernel(0) = %v, want 1", v)
+	}
+}
+
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestResizeLanczos_Dimensions(t *testing.T) {
+	src := solidImage(20, 10, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+	dst := resizeLanczos(src, 8, 4)
+	b := dst.Bounds()
+	if b.Dx() != 8 || b.Dy() != 4 {
+		t.Fatalf("resizeLanczos dims = %dx%d, want 8x4", b.Dx(), b.Dy())
+	}
+}
+
+func TestResizeLanczos_SolidColorStaysSolid(t *testing.T) {
+	c := color.RGBA{R: 10, G: 200, B: 30, A: 255}
+	src := solidImage(16, 16, c)
+	dst := resizeLanczos(src, 5, 5)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			got := dst.RGBAAt(x, y)
+			if got.R != c.R || got.G != c.G || got.B != c.B {
+				t.Errorf("pixel (%d,%d) = %v, want %v (flat color must resample to itself)", x, y, got, c)
+			}
+		}
+	}
+}
+
+func TestResizeFit_DoesNotUpscale(t *testing.T) {
+	src := solidImage(10, 10, color.RGBA{A: 255})
+	out := resizeFit(src, 100, 100)
+	if out != image.Image(src) {
+		t.Errorf("resizeFit should return the original image unchanged when it's already within bounds")
+	}
+}
+
+func TestResizeFit_PreservesAspectRatio(t *testing.T) {
+	src := solidImage(200, 100, color.RGBA{A: 255}) // 2:1
+	out := resizeFit(src, 50, 50)
+	b := out.Bounds()
+	if b.Dx() != 50 || b.Dy() != 25 {
+		t.Errorf("resizeFit(200x100 -> fit 50x50) = %dx%d, want 50x25", b.Dx(), b.Dy())
+	}
+}
+
+func TestResizeExact_UsesRequestedDimensions(t *testing.T) {
+	src := solidImage(200, 100, color.RGBA{A: 255})
+	out := resizeExact(src, 40, 40)
+	b := out.Bounds()
+	if b.Dx() != 40 || b.Dy() != 40 {
+		t.Errorf("resizeExact(40,40) = %dx%d, want 40x40", b.Dx(), b.Dy())
+	}
+}
+
+func TestResizeExact_InfersMissingDimension(t *testing.T) {
+	src := solidImage(200, 100, color.RGBA{A: 255}) // 2:1
+	out := resizeExact(src, 40, 0)
+	b := out.Bounds()
+	if b.Dx() != 40 || b.Dy() != 20 {
+		t.Errorf("resizeExact(40,0) = %dx%d, want 40x20", b.Dx(), b.Dy())
+	}
+}
+
+func TestResizeThumbnail_ExactOutputSize(t *testing.T) {
+	src := solidImage(300, 100, color.RGBA{A: 255})
+	out := resizeThumbnail(src, 50, 50)
+	b := out.Bounds()
+	if b.Dx() != 50 || b.Dy() != 50 {
+		t.Errorf("resizeThumbnail(50,50) = %dx%d, want 50x50", b.Dx(), b.Dy())
+	}
+}